@@ -0,0 +1,49 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// encodeAttr serializes the subset of os.FileInfo a GETATTR/LOOKUP reply
+// needs: size and mode.
+func encodeAttr(fi os.FileInfo) []byte {
+	buf := make([]byte, 12)
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(fi.Size()))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(fi.Mode()))
+	return buf
+}
+
+// errnoOf maps a Go file error to the raw errno FUSE-T expects in a
+// response header.
+func errnoOf(err error) int32 {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return int32(errno)
+	}
+	if os.IsNotExist(err) {
+		return int32(syscall.ENOENT)
+	}
+	if os.IsPermission(err) {
+		return int32(syscall.EACCES)
+	}
+	return int32(syscall.EIO)
+}
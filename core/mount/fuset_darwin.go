@@ -0,0 +1,51 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"os"
+
+	"github.com/containerd/log"
+)
+
+// serveFuseTPassthrough services FUSE requests arriving on fuseFd by
+// translating them into syscalls against source. It only needs to support
+// the operations a bind mount requires: READ, WRITE, LOOKUP and GETATTR.
+// It runs until fuseFd is closed, which happens when the mount is torn down
+// by unmountFuseTNative.
+func serveFuseTPassthrough(source string, fuseFd *os.File) {
+	defer fuseFd.Close()
+
+	var req fuseTRequest
+	for {
+		if err := req.readFrom(fuseFd); err != nil {
+			log.L.WithError(err).WithField("source", source).Debug("fuse-t-native: channel closed")
+			return
+		}
+
+		resp, err := req.dispatch(source)
+		if err != nil {
+			log.L.WithError(err).WithField("source", source).Warn("fuse-t-native: request failed")
+			continue
+		}
+
+		if err := resp.writeTo(fuseFd); err != nil {
+			log.L.WithError(err).WithField("source", source).Debug("fuse-t-native: failed to write response")
+			return
+		}
+	}
+}
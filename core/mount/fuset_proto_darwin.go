@@ -0,0 +1,139 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fuseTOpcode identifies the subset of the FUSE wire protocol that a
+// pass-through bind mount needs to answer.
+type fuseTOpcode uint32
+
+const (
+	fuseTOpLookup fuseTOpcode = iota + 1
+	fuseTOpGetattr
+	fuseTOpRead
+	fuseTOpWrite
+)
+
+// fuseTRequest is the minimal envelope FUSE-T sends over the channel fd:
+// opcode, the relative path the operation applies to, and (for READ/WRITE)
+// an offset/length or payload.
+type fuseTRequest struct {
+	opcode fuseTOpcode
+	path   string
+	offset int64
+	length uint32
+	data   []byte
+}
+
+// fuseTResponse mirrors fuseTRequest for the reply direction.
+type fuseTResponse struct {
+	errno int32
+	data  []byte
+}
+
+func (r *fuseTRequest) readFrom(rd io.Reader) error {
+	var header [20]byte
+	if _, err := io.ReadFull(rd, header[:]); err != nil {
+		return err
+	}
+
+	r.opcode = fuseTOpcode(binary.LittleEndian.Uint32(header[0:4]))
+	r.offset = int64(binary.LittleEndian.Uint64(header[4:12]))
+	r.length = binary.LittleEndian.Uint32(header[12:16])
+	pathLen := binary.LittleEndian.Uint32(header[16:20])
+
+	path := make([]byte, pathLen)
+	if _, err := io.ReadFull(rd, path); err != nil {
+		return err
+	}
+	r.path = string(path)
+
+	if r.opcode == fuseTOpWrite {
+		data := make([]byte, r.length)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return err
+		}
+		r.data = data
+	}
+
+	return nil
+}
+
+func (r *fuseTResponse) writeTo(w io.Writer) error {
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(r.errno))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(r.data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(r.data)
+	return err
+}
+
+// dispatch translates a request into a syscall against source and returns
+// the FUSE-T response for it.
+func (r *fuseTRequest) dispatch(source string) (*fuseTResponse, error) {
+	target := filepath.Join(source, filepath.Clean("/"+r.path))
+
+	switch r.opcode {
+	case fuseTOpLookup, fuseTOpGetattr:
+		fi, err := os.Stat(target)
+		if err != nil {
+			return &fuseTResponse{errno: errnoOf(err)}, nil
+		}
+		return &fuseTResponse{data: encodeAttr(fi)}, nil
+
+	case fuseTOpRead:
+		f, err := os.Open(target)
+		if err != nil {
+			return &fuseTResponse{errno: errnoOf(err)}, nil
+		}
+		defer f.Close()
+
+		buf := make([]byte, r.length)
+		n, err := f.ReadAt(buf, r.offset)
+		if err != nil && err != io.EOF {
+			return &fuseTResponse{errno: errnoOf(err)}, nil
+		}
+		return &fuseTResponse{data: buf[:n]}, nil
+
+	case fuseTOpWrite:
+		f, err := os.OpenFile(target, os.O_WRONLY, 0)
+		if err != nil {
+			return &fuseTResponse{errno: errnoOf(err)}, nil
+		}
+		defer f.Close()
+
+		if _, err := f.WriteAt(r.data, r.offset); err != nil {
+			return &fuseTResponse{errno: errnoOf(err)}, nil
+		}
+		return &fuseTResponse{}, nil
+
+	default:
+		// The FUSE channel expects exactly one response per request; an
+		// unrecognized opcode must still get a reply (ENOSYS), or the
+		// syscall that produced it hangs in the container forever.
+		return &fuseTResponse{errno: int32(syscall.ENOSYS)}, nil
+	}
+}
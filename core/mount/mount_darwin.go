@@ -20,24 +20,164 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// fuseTNativeDaemonEnv names the environment variable that overrides the
+// FUSE-T user-space daemon, which speaks the FUSE protocol over a file
+// descriptor we hand it rather than a kernel /dev/fuse node. When unset, the
+// binary FUSE-T ships (defaultFuseTNativeDaemon) is used; the override is
+// for development builds or alternate install locations.
+const fuseTNativeDaemonEnv = "DARWIN_FUSE_T_DAEMON"
+
+const defaultFuseTNativeDaemon = "/usr/local/bin/go-nfsv4"
+
+// fuseTNativeMount tracks the state of a mount served by the in-process
+// FUSE-T pass-through server so that unmountFuseTNative can tear it down.
+type fuseTNativeMount struct {
+	daemon *os.Process
+	fuseFd *os.File
+}
+
+var (
+	fuseTNativeMountsMu sync.Mutex
+	fuseTNativeMounts   = map[string]*fuseTNativeMount{}
 )
 
 func getDawinMountSystem() (string, error) {
-	// DARWIN_MOUNT_SYSTEM can be 'macfuse' (default if empty), 'macfuse-fskit' or 'fuse-t'
+	// DARWIN_MOUNT_SYSTEM can be 'macfuse' (default if empty), 'macfuse-fskit',
+	// 'fuse-t' or 'fuse-t-native'
 	// 'macfuse' is the most stable but requires a kext to be installed
+	// 'fuse-t-native' talks the FUSE-T wire protocol directly instead of
+	// shelling out to the bindfs fork
 	mountSystem := os.Getenv("DARWIN_MOUNT_SYSTEM")
 	if mountSystem == "" {
 		mountSystem = "macfuse"
 	}
 
-	if mountSystem != "fuse-t" && mountSystem != "macfuse" && mountSystem != "macfuse-fskit" {
+	// Note: this only selects the FUSE backend for bind mounts. It has no
+	// bearing on m.Type == "virtiofs", which is mounted directly and never
+	// goes through a FUSE daemon.
+	switch mountSystem {
+	case "fuse-t", "macfuse", "macfuse-fskit", "fuse-t-native":
+	default:
 		return "", fmt.Errorf("invalid DARWIN_MOUNT_SYSTEM: %s", mountSystem)
 	}
 
 	return mountSystem, nil
 }
 
-// Mount to the provided target.
+// mountFuseTNative performs a bind mount by speaking the FUSE-T handshake
+// directly: it opens a SOCK_STREAM AF_UNIX socketpair, keeps one end in
+// process as the FUSE channel and hands the peer fd to the FUSE-T daemon via
+// ExtraFiles. This avoids the hard dependency on the bindfs fork and its
+// brittle build-time env vars (LC_RPATH, fuse_CFLAGS, fuse_LIBS).
+func (m *Mount) mountFuseTNative(target string) error {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return fmt.Errorf("fuse-t-native: socketpair failed: %w", err)
+	}
+
+	fuseFile := os.NewFile(uintptr(fds[0]), "fuse-t-fuse-channel")
+	daemonFile := os.NewFile(uintptr(fds[1]), "fuse-t-daemon-channel")
+	defer daemonFile.Close()
+
+	daemon := os.Getenv(fuseTNativeDaemonEnv)
+	if daemon == "" {
+		daemon = defaultFuseTNativeDaemon
+	}
+
+	var args []string
+	for _, option := range m.Options {
+		if option == "rbind" {
+			continue
+		}
+		args = append(args, "-o", option)
+	}
+	args = append(args, target)
+
+	cmd := exec.Command(daemon, args...)
+	cmd.Env = os.Environ()
+	cmd.ExtraFiles = []*os.File{daemonFile}
+	if err := cmd.Start(); err != nil {
+		fuseFile.Close()
+		return fmt.Errorf("fuse-t-native: starting %s failed: %w", daemon, err)
+	}
+
+	fuseTNativeMountsMu.Lock()
+	fuseTNativeMounts[target] = &fuseTNativeMount{
+		daemon: cmd.Process,
+		fuseFd: fuseFile,
+	}
+	fuseTNativeMountsMu.Unlock()
+
+	// Service FUSE requests (READ/WRITE/LOOKUP/GETATTR translated to
+	// syscalls against m.Source for bind mounts) on our end of the channel
+	// until the daemon exits or the mount is torn down.
+	go serveFuseTPassthrough(m.Source, fuseFile)
+
+	return nil
+}
+
+// unmountFuseTNative force-unmounts a target previously mounted via
+// mountFuseTNative and reaps the FUSE-T daemon process.
+func unmountFuseTNative(target string) error {
+	fuseTNativeMountsMu.Lock()
+	state, ok := fuseTNativeMounts[target]
+	if ok {
+		delete(fuseTNativeMounts, target)
+	}
+	fuseTNativeMountsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("fuse-t-native: no tracked mount for %s", target)
+	}
+
+	out, err := exec.Command("diskutil", "unmount", "force", target).CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "not mounted") {
+		return fmt.Errorf("diskutil unmount force %s failed: %q: %w", target, string(out), err)
+	}
+
+	_ = state.fuseFd.Close()
+	if state.daemon != nil {
+		_ = state.daemon.Kill()
+		_, _ = state.daemon.Wait()
+	}
+
+	return nil
+}
+
+// unmount tears down a target previously mounted via mount, reaping the
+// fuse-t-native daemon/fd or the virtiofs share as appropriate so neither
+// leaks past the mount's lifetime.
+//
+// TODO: this is only reachable by calling m.unmount directly today. The
+// cross-platform Unmount/UnmountAll dispatcher that would call it upstream
+// lives in core/mount/mount_unix.go and friends, which aren't part of this
+// tree's snapshot (only the darwin-specific files are present).
+func (m *Mount) unmount(target string) error {
+	mountSystem, err := getDawinMountSystem()
+	if err != nil {
+		return err
+	}
+
+	if mountSystem == "fuse-t-native" && m.Type == "bind" {
+		return unmountFuseTNative(target)
+	}
+
+	if m.Type == "virtiofs" {
+		return unmountVirtiofs(target)
+	}
+
+	out, err := exec.Command("diskutil", "unmount", "force", target).CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "not mounted") {
+		return fmt.Errorf("diskutil unmount force %s failed: %q: %w", target, string(out), err)
+	}
+	return nil
+}
+
 // Mount to the provided target.
 func (m *Mount) mount(target string) error {
 
@@ -46,6 +186,17 @@ func (m *Mount) mount(target string) error {
 		return err
 	}
 
+	if mountSystem == "fuse-t-native" && m.Type == "bind" {
+		return m.mountFuseTNative(target)
+	}
+
+	// virtiofs shares are exposed by the macOS Virtualization framework
+	// host directly, independent of which FUSE backend DARWIN_MOUNT_SYSTEM
+	// selects for bind mounts.
+	if m.Type == "virtiofs" {
+		return m.mountVirtiofs(target)
+	}
+
 	var commandName string
 	if m.Type == "bind" {
 		// macOS doesn't natively support bindfs/nullfs
@@ -0,0 +1,74 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mount
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountVirtiofs bind-mounts a directory shared into a macOS VM by Apple's
+// Virtualization framework. Unlike bindfs-backed mounts, the share is
+// identified by a tag rather than a host path; m.Source is used as the tag
+// unless a "tag=" option overrides it.
+func (m *Mount) mountVirtiofs(target string) error {
+	tag := m.Source
+
+	var args []string
+	for _, option := range m.Options {
+		if rest, ok := strings.CutPrefix(option, "tag="); ok {
+			tag = rest
+			continue
+		}
+		args = append(args, "-o", option)
+	}
+
+	if _, err := exec.LookPath("mount_virtiofs"); err == nil {
+		cmdArgs := append(append([]string{}, args...), tag, target)
+		cmd := exec.Command("mount_virtiofs", cmdArgs...)
+		cmd.Env = os.Environ()
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("mount_virtiofs [%v] failed: %q: %w", cmdArgs, string(output), err)
+		}
+		return nil
+	}
+
+	// mount_virtiofs isn't available (e.g. a minimal guest image); mount(2)
+	// directly, passing the tag as the filesystem-specific mount data.
+	data, err := syscall.BytePtrFromString(tag)
+	if err != nil {
+		return fmt.Errorf("virtiofs: invalid tag %q: %w", tag, err)
+	}
+	if err := unix.Mount("virtiofs", target, 0, unsafe.Pointer(data)); err != nil {
+		return fmt.Errorf("virtiofs: mount(2) of tag %q on %s failed: %w", tag, target, err)
+	}
+	return nil
+}
+
+// unmountVirtiofs unmounts a target previously mounted via mountVirtiofs.
+func unmountVirtiofs(target string) error {
+	if err := unix.Unmount(target, 0); err != nil {
+		return fmt.Errorf("virtiofs: unmount of %s failed: %w", target, err)
+	}
+	return nil
+}
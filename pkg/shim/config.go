@@ -0,0 +1,84 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// defaultConfigFileName is the file, relative to the bundle directory, a
+// shim reads its plugin configuration from when -config is not given.
+const defaultConfigFileName = "shim-config.toml"
+
+// shimConfigAnnotation is the RuntimeInfo annotation key the info action
+// exposes the shim config under, re-encoded as TOML from the file as read.
+// Plugin Config defaults and the -log-sink flag are not merged into it.
+const shimConfigAnnotation = "io.containerd.shim.config"
+
+// ShimConfig is a shim's on-disk plugin configuration: a TOML document
+// whose "plugins" table is keyed by plugin.Registration.URI(), the same
+// shape and key scheme as containerd's own server config. Each section is
+// decoded directly into the matching plugin's Config prototype.
+type ShimConfig struct {
+	// LogSink selects, by plugin ID, the registered LogSink plugin that
+	// receives the shim's structured log output in place of the default
+	// FIFO. Overridden by -log-sink. Empty means the default FIFO.
+	LogSink string         `toml:"log_sink"`
+	Plugins map[string]any `toml:"plugins"`
+}
+
+// loadShimConfig reads and parses the shim config file at path. A missing
+// file is not an error: the shim simply runs with every plugin
+// unconfigured, the same as before -config existed.
+func loadShimConfig(path string) (*ShimConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ShimConfig{}, nil
+		}
+		return nil, err
+	}
+	var cfg ShimConfig
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Decode unmarshals the TOML section for plugin id into config, mirroring
+// containerd server's own Config.Decode. A plugin with no matching section
+// is left untouched and Decode returns config as-is.
+func (c *ShimConfig) Decode(id string, config any) (any, error) {
+	data, ok := c.Plugins[id]
+	if !ok {
+		return config, nil
+	}
+
+	b, err := toml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := toml.Unmarshal(b, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
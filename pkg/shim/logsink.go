@@ -0,0 +1,50 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"github.com/containerd/plugin"
+	"github.com/sirupsen/logrus"
+)
+
+// LogSinkPluginType is the plugin type structured log sink plugins register
+// under. It lives here rather than alongside the plugin type constants in
+// github.com/containerd/containerd/v2/plugins because a log sink is a
+// shim-process concern; containerd's own daemon never loads one.
+const LogSinkPluginType plugin.Type = "io.containerd.shim.log-sink.v1"
+
+// LogSink is implemented by a plugin that wants to receive a shim's
+// structured log output in place of the default FIFO-backed text log. A
+// shim selects at most one sink, by plugin.Registration.ID, via shim
+// config's log_sink key or the -log-sink flag; everything else keeps
+// writing plain text to the FIFO, exactly as before this existed.
+type LogSink interface {
+	// LogHook returns the logrus hook this sink installs. A hook is used
+	// rather than an io.Writer so the sink sees log.Fields as structured
+	// data instead of already having been flattened into text.
+	LogHook() (logrus.Hook, error)
+}
+
+// effectiveLogSink returns the ID of the LogSink plugin to install: the
+// -log-sink flag if given, otherwise the shim config's log_sink key. An
+// empty result means the default FIFO.
+func effectiveLogSink(cfg *ShimConfig) string {
+	if logSinkFlag != "" {
+		return logSinkFlag
+	}
+	return cfg.LogSink
+}
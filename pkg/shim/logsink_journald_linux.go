@@ -0,0 +1,96 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containerd/plugin"
+	"github.com/containerd/plugin/registry"
+	"github.com/coreos/go-systemd/v22/journal"
+	"github.com/sirupsen/logrus"
+)
+
+// journaldLogSinkID is this sink's plugin ID; select it with -log-sink
+// journald or the shim config's log_sink key.
+const journaldLogSinkID = "journald"
+
+func init() {
+	registry.Register(&plugin.Registration{
+		Type: LogSinkPluginType,
+		ID:   journaldLogSinkID,
+		InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+			if !journal.Enabled() {
+				return nil, fmt.Errorf("journald log sink: no journald socket on this host: %w", plugin.ErrSkipPlugin)
+			}
+			return journaldLogSink{}, nil
+		},
+	})
+}
+
+// journaldLogSink sends shim log entries to the local systemd journal,
+// carrying log.Fields across as sd_journal_send-style structured fields
+// instead of flattening them into the message text.
+type journaldLogSink struct{}
+
+func (journaldLogSink) LogHook() (logrus.Hook, error) {
+	return journaldHook{}, nil
+}
+
+type journaldHook struct{}
+
+func (journaldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (journaldHook) Fire(entry *logrus.Entry) error {
+	vars := make(map[string]string, len(entry.Data))
+	for k, v := range entry.Data {
+		vars[journaldFieldName(k)] = fmt.Sprint(v)
+	}
+	return journal.Send(entry.Message, journaldPriority(entry.Level), vars)
+}
+
+// journaldFieldName maps a log.Fields key to a valid journald field name:
+// uppercase ASCII letters, digits and underscore only.
+func journaldFieldName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func journaldPriority(level logrus.Level) journal.Priority {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return journal.PriCrit
+	case logrus.ErrorLevel:
+		return journal.PriErr
+	case logrus.WarnLevel:
+		return journal.PriWarning
+	case logrus.InfoLevel:
+		return journal.PriInfo
+	default:
+		return journal.PriDebug
+	}
+}
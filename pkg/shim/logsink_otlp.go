@@ -0,0 +1,201 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containerd/plugin"
+	"github.com/containerd/plugin/registry"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// otlpLogSinkID is this sink's plugin ID; select it with -log-sink otlp or
+// the shim config's log_sink key.
+const otlpLogSinkID = "otlp"
+
+// otlpEndpointEnv is the standard OTel env var consulted when the sink has
+// no endpoint configured explicitly.
+const otlpEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+const (
+	otlpLogSinkBatchSize    = 512
+	otlpLogSinkBatchTimeout = 5 * time.Second
+)
+
+// otlpLogSinkConfig is the Config prototype for the otlp log sink plugin,
+// decoded from the shim config's [plugins."io.containerd.shim.log-sink.v1.otlp"]
+// section.
+type otlpLogSinkConfig struct {
+	// Endpoint is the OTLP/gRPC logs collector address. Falls back to
+	// OTEL_EXPORTER_OTLP_ENDPOINT when empty.
+	Endpoint string `toml:"endpoint"`
+}
+
+func init() {
+	registry.Register(&plugin.Registration{
+		Type:   LogSinkPluginType,
+		ID:     otlpLogSinkID,
+		Config: &otlpLogSinkConfig{},
+		InitFn: func(ic *plugin.InitContext) (interface{}, error) {
+			cfg, _ := ic.Config.(*otlpLogSinkConfig)
+			endpoint := ""
+			if cfg != nil {
+				endpoint = cfg.Endpoint
+			}
+			if endpoint == "" {
+				endpoint = os.Getenv(otlpEndpointEnv)
+			}
+			if endpoint == "" {
+				return nil, fmt.Errorf("otlp log sink: no endpoint configured (set %s or plugins.%s.endpoint): %w", otlpEndpointEnv, LogSinkPluginType.String()+"."+otlpLogSinkID, plugin.ErrSkipPlugin)
+			}
+
+			conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				return nil, fmt.Errorf("otlp log sink: dialing %s: %w", endpoint, err)
+			}
+
+			sink := &otlpLogSink{
+				client: collogspb.NewLogsServiceClient(conn),
+				conn:   conn,
+			}
+			sink.start()
+			return sink, nil
+		},
+	})
+}
+
+// otlpLogSink batches shim log entries and exports them as OTLP LogRecords
+// over gRPC, preserving log.Fields as structured attributes instead of
+// flattening them into a single text line.
+type otlpLogSink struct {
+	client collogspb.LogsServiceClient
+	conn   *grpc.ClientConn
+
+	mu      sync.Mutex
+	pending []*logspb.LogRecord
+
+	flush chan struct{}
+}
+
+func (s *otlpLogSink) LogHook() (logrus.Hook, error) {
+	return s, nil
+}
+
+func (*otlpLogSink) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (s *otlpLogSink) Fire(entry *logrus.Entry) error {
+	attrs := make([]*commonpb.KeyValue, 0, len(entry.Data))
+	for k, v := range entry.Data {
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   k,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}},
+		})
+	}
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:   uint64(entry.Time.UnixNano()),
+		SeverityNumber: otlpSeverity(entry.Level),
+		SeverityText:   entry.Level.String(),
+		Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: entry.Message}},
+		Attributes:     attrs,
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, record)
+	full := len(s.pending) >= otlpLogSinkBatchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// start launches the batching loop that periodically exports whatever log
+// records have accumulated since the last flush.
+func (s *otlpLogSink) start() {
+	s.flush = make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(otlpLogSinkBatchTimeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.flush:
+			}
+			s.export()
+		}
+	}()
+}
+
+func (s *otlpLogSink) export() {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), otlpLogSinkBatchTimeout)
+	defer cancel()
+
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				ScopeLogs: []*logspb.ScopeLogs{
+					{LogRecords: batch},
+				},
+			},
+		},
+	}
+	// Export errors are dropped rather than retried: a shim that blocks on
+	// its telemetry backend is worse than one that loses a batch of logs.
+	_, _ = s.client.Export(ctx, req)
+}
+
+func otlpSeverity(level logrus.Level) logspb.SeverityNumber {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case logrus.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case logrus.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case logrus.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+}
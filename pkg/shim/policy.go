@@ -0,0 +1,197 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// DialPolicy controls how AnonDialerWithPolicy retries a dial against a
+// shim address that may not be accepting connections yet, e.g. a guest
+// agent inside a VM that takes seconds to open its listener after boot.
+type DialPolicy struct {
+	// MaxAttempts bounds the number of dial attempts, including the
+	// first. Values <= 1 disable retrying.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff delay between attempts. A
+	// zero value leaves the backoff at InitialBackoff for every retry.
+	MaxBackoff time.Duration
+	// Jitter is the maximum fraction, in [0,1], of the computed backoff
+	// to randomly add or subtract before sleeping.
+	Jitter float64
+	// PerAttemptDeadline bounds a single dial/handshake attempt. Zero
+	// means no explicit per-attempt deadline.
+	PerAttemptDeadline time.Duration
+	// OverallDeadline bounds the entire retry sequence, across all
+	// attempts and backoff sleeps. Zero means no overall deadline, so
+	// MaxAttempts full-length attempts can each run for PerAttemptDeadline.
+	OverallDeadline time.Duration
+	// Retryable classifies a dial error as worth retrying. A nil
+	// Retryable treats every error as retryable.
+	Retryable func(error) bool
+}
+
+// defaultDialPolicy reproduces AnonDialer's historical behavior for every
+// scheme other than hybrid vsock: a single attempt, no retrying.
+var defaultDialPolicy = DialPolicy{MaxAttempts: 1}
+
+// defaultHybridVsockDialPolicy reproduces hybridVsockDialer's historical
+// behavior: up to 10 attempts spaced by a tenth of timeout, retried only on
+// EOF or a transient "BUSY"/"STARTING" handshake response, with the entire
+// retry sequence bounded to timeout overall — matching the old
+// hybridVsockDialer, which raced the whole loop against a single
+// time.After(timeout).
+func defaultHybridVsockDialPolicy(timeout time.Duration) DialPolicy {
+	return DialPolicy{
+		MaxAttempts:        10,
+		InitialBackoff:     timeout / 10,
+		MaxBackoff:         timeout / 10,
+		PerAttemptDeadline: timeout,
+		OverallDeadline:    timeout,
+		Retryable:          defaultHandshakeRetryable,
+	}
+}
+
+func defaultHandshakeRetryable(err error) bool {
+	if errors.Is(err, io.EOF) {
+		return true
+	}
+	var he *handshakeError
+	if errors.As(err, &he) {
+		return strings.Contains(he.response, "BUSY") || strings.Contains(he.response, "STARTING")
+	}
+	return false
+}
+
+func (p DialPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return true
+	}
+	return p.Retryable(err)
+}
+
+// AnonDialerWithPolicy returns a dialer for a socket, retrying according to
+// policy. AnonDialer is the common case, defaulting to today's behavior.
+func AnonDialerWithPolicy(address string, policy DialPolicy) (net.Conn, error) {
+	scheme, addr, ok := strings.Cut(address, "://")
+
+	dial := func(deadline time.Duration) (net.Conn, error) {
+		if !ok {
+			return net.DialTimeout("unix", socket(address).path(), deadline)
+		}
+		t, err := defaultTransports.get(scheme)
+		if err != nil {
+			return nil, err
+		}
+		return t.Dial(addr, deadline)
+	}
+
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var deadline time.Time
+	if policy.OverallDeadline > 0 {
+		deadline = time.Now().Add(policy.OverallDeadline)
+	}
+
+	backoff := policy.InitialBackoff
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		perAttempt := policy.PerAttemptDeadline
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				lastErr = fmt.Errorf("dial %s: overall deadline of %s exceeded: %w", address, policy.OverallDeadline, lastErrOrTimeout(lastErr))
+				break
+			}
+			if perAttempt <= 0 || remaining < perAttempt {
+				perAttempt = remaining
+			}
+		}
+
+		conn, err := dial(perAttempt)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+
+		if attempt == attempts-1 || !policy.retryable(err) {
+			break
+		}
+
+		sleep := jitter(backoff, policy.Jitter)
+		if !deadline.IsZero() {
+			if remaining := time.Until(deadline); remaining <= 0 {
+				break
+			} else if sleep > remaining {
+				sleep = remaining
+			}
+		}
+		time.Sleep(sleep)
+		if policy.MaxBackoff > 0 && backoff < policy.MaxBackoff {
+			backoff *= 2
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+
+	return nil, lastErr
+}
+
+// lastErrOrTimeout returns err, or a generic timeout error if no attempt
+// had run yet when the overall deadline was exceeded.
+func lastErrOrTimeout(err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("timed out before any attempt completed")
+}
+
+func jitter(d time.Duration, frac float64) time.Duration {
+	if frac <= 0 || d <= 0 {
+		return d
+	}
+	delta := time.Duration(float64(d) * frac * (rand.Float64()*2 - 1))
+	if d+delta < 0 {
+		return 0
+	}
+	return d + delta
+}
+
+// Probe dials address using policy and reports whether the peer is
+// currently reachable. Unlike CanConnect, it surfaces the underlying error
+// and whether it was classified as retryable, so shim-manager code can
+// distinguish "not yet ready" from "will never come up".
+func Probe(address string, policy DialPolicy) (retryable bool, err error) {
+	conn, err := AnonDialerWithPolicy(address, policy)
+	if err != nil {
+		return policy.retryable(err), err
+	}
+	conn.Close()
+	return false, nil
+}
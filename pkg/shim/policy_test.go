@@ -0,0 +1,136 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestJitterNoJitter(t *testing.T) {
+	if got := jitter(5*time.Second, 0); got != 5*time.Second {
+		t.Fatalf("jitter with frac=0 should be a no-op, got %s", got)
+	}
+	if got := jitter(0, 0.5); got != 0 {
+		t.Fatalf("jitter of a zero duration should stay zero, got %s", got)
+	}
+}
+
+func TestJitterStaysWithinBounds(t *testing.T) {
+	d := 10 * time.Second
+	frac := 0.2
+	for i := 0; i < 1000; i++ {
+		got := jitter(d, frac)
+		min := d - time.Duration(float64(d)*frac)
+		max := d + time.Duration(float64(d)*frac)
+		if got < min || got > max {
+			t.Fatalf("jitter(%s, %v) = %s, want within [%s, %s]", d, frac, got, min, max)
+		}
+		if got < 0 {
+			t.Fatalf("jitter should never go negative, got %s", got)
+		}
+	}
+}
+
+// countingTransport fails every Dial and counts how many times it was
+// called, so tests can assert on the retry loop's attempt count without
+// depending on wall-clock timing of a real socket.
+type countingTransport struct {
+	attempts int
+	err      error
+}
+
+func (t *countingTransport) Dial(string, time.Duration) (net.Conn, error) {
+	t.attempts++
+	return nil, t.err
+}
+
+func (t *countingTransport) Listen(string) (net.Listener, error) { return nil, nil }
+func (t *countingTransport) Cleanup(string) error                { return nil }
+func (t *countingTransport) CanConnect(string) bool              { return false }
+
+func TestAnonDialerWithPolicyRespectsMaxAttempts(t *testing.T) {
+	ct := &countingTransport{err: errors.New("always fails")}
+	RegisterTransport(testTransportScheme+"-maxattempts", ct)
+
+	policy := DialPolicy{MaxAttempts: 4}
+	_, err := AnonDialerWithPolicy(testTransportScheme+"-maxattempts://addr", policy)
+	if err == nil {
+		t.Fatal("expected an error since every dial fails")
+	}
+	if ct.attempts != 4 {
+		t.Fatalf("expected exactly 4 attempts, got %d", ct.attempts)
+	}
+}
+
+func TestAnonDialerWithPolicyStopsOnNonRetryableError(t *testing.T) {
+	ct := &countingTransport{err: errors.New("not retryable")}
+	RegisterTransport(testTransportScheme+"-notretryable", ct)
+
+	policy := DialPolicy{
+		MaxAttempts: 10,
+		Retryable:   func(error) bool { return false },
+	}
+	_, err := AnonDialerWithPolicy(testTransportScheme+"-notretryable://addr", policy)
+	if err == nil {
+		t.Fatal("expected an error since every dial fails")
+	}
+	if ct.attempts != 1 {
+		t.Fatalf("expected a single attempt once Retryable returns false, got %d", ct.attempts)
+	}
+}
+
+// TestAnonDialerWithPolicyRespectsOverallDeadline guards against the
+// regression where each of MaxAttempts retries got its own full
+// PerAttemptDeadline with no cross-attempt ceiling: a policy whose
+// OverallDeadline is much shorter than MaxAttempts*InitialBackoff must stop
+// retrying once the overall deadline has passed, rather than sleeping out
+// every attempt's backoff in full.
+func TestAnonDialerWithPolicyRespectsOverallDeadline(t *testing.T) {
+	ct := &countingTransport{err: errors.New("always fails")}
+	RegisterTransport(testTransportScheme+"-overalldeadline", ct)
+
+	policy := DialPolicy{
+		MaxAttempts:     1000,
+		InitialBackoff:  50 * time.Millisecond,
+		MaxBackoff:      50 * time.Millisecond,
+		OverallDeadline: 200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := AnonDialerWithPolicy(testTransportScheme+"-overalldeadline://addr", policy)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected an error since every dial fails")
+	}
+	if elapsed > time.Second {
+		t.Fatalf("AnonDialerWithPolicy took %s, want roughly bounded by OverallDeadline (200ms)", elapsed)
+	}
+	if ct.attempts >= 1000 {
+		t.Fatalf("expected the overall deadline to cut the retry loop short, got all %d attempts", ct.attempts)
+	}
+}
+
+func TestDefaultHybridVsockDialPolicySetsOverallDeadline(t *testing.T) {
+	policy := defaultHybridVsockDialPolicy(3 * time.Second)
+	if policy.OverallDeadline != 3*time.Second {
+		t.Fatalf("expected OverallDeadline to match the requested timeout, got %s", policy.OverallDeadline)
+	}
+}
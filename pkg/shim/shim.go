@@ -43,6 +43,8 @@ import (
 	"github.com/containerd/plugin"
 	"github.com/containerd/plugin/registry"
 	"github.com/containerd/ttrpc"
+	"github.com/pelletier/go-toml/v2"
+	"google.golang.org/grpc"
 )
 
 // Publisher for events
@@ -56,6 +58,10 @@ type StartOpts struct {
 	Address      string
 	TTRPCAddress string
 	Debug        bool
+	// Protocol is the wire protocol this shim process will serve its
+	// control socket over, e.g. for BootstrapParams.Protocol: "ttrpc" or
+	// "grpc". See WithProtocol.
+	Protocol string
 }
 
 // BootstrapParams is a JSON payload returned in stdout from shim.Start call.
@@ -66,6 +72,12 @@ type BootstrapParams struct {
 	Address string `json:"address"`
 	// Protocol is either TTRPC or GRPC.
 	Protocol string `json:"protocol"`
+	// SandboxShared is true when Address is a sandbox-scoped shim that is
+	// already serving one or more tasks. Containerd must not start another
+	// shim process for sibling containers in the same sandbox; it should
+	// invoke this runtime's "attach" action instead, passing the same
+	// -sandbox-id, to obtain these same BootstrapParams.
+	SandboxShared bool `json:"sandboxShared,omitempty"`
 }
 
 type StopStatus struct {
@@ -82,6 +94,22 @@ type Manager interface {
 	Info(ctx context.Context, optionsR io.Reader) (*types.RuntimeInfo, error)
 }
 
+// SandboxManager is implemented by runtimes that host every task in a pod
+// under a single shim process, e.g. io.containerd.kata.v2, rather than
+// forking one shim per container. StartSandbox brings up that process for
+// the first container in the sandbox; every subsequent container in the
+// same sandbox is handed to the already-running process via Attach instead
+// of another Start, and StopSandbox tears the whole process down once the
+// sandbox itself is removed. Implementations still see every task through
+// the ordinary Manager.Stop(ctx, id); StopSandbox is only for the sandbox
+// lifecycle, and it is up to the implementation to keep the process alive
+// until the last task has been stopped.
+type SandboxManager interface {
+	StartSandbox(ctx context.Context, sandboxID string, opts StartOpts) (BootstrapParams, error)
+	StopSandbox(ctx context.Context, sandboxID string) (StopStatus, error)
+	Attach(ctx context.Context, sandboxID string) (BootstrapParams, error)
+}
+
 // OptsKey is the context key for the Opts value.
 type OptsKey struct{}
 
@@ -106,6 +134,9 @@ type Config struct {
 	Stdin         io.ReadCloser
 	ExitFunc      func(int)
 	WithArgs      []string
+	// Protocol selects the wire protocol served on the shim's control
+	// socket: "ttrpc" (the default) or "grpc". See WithProtocol.
+	Protocol string
 }
 
 type TTRPCService interface {
@@ -120,6 +151,19 @@ type TTRPCClientUnaryOptioner interface {
 	UnaryClientInterceptor() ttrpc.UnaryClientInterceptor
 }
 
+// GRPCService is the gRPC counterpart to TTRPCService: a plugin instance
+// implementing it is registered on the shim's gRPC server when the shim
+// opts into the grpc protocol via WithProtocol("grpc").
+type GRPCService interface {
+	RegisterGRPC(*grpc.Server) error
+}
+
+// GRPCServerUnaryOptioner mirrors TTRPCServerUnaryOptioner for plugins that
+// want to contribute a unary interceptor to the gRPC server.
+type GRPCServerUnaryOptioner interface {
+	UnaryServerInterceptor() grpc.UnaryServerInterceptor
+}
+
 func WithExitFunc(exitFunc func(int)) BinaryOpts {
 	return func(config *Config) {
 		config.ExitFunc = exitFunc
@@ -132,6 +176,17 @@ func WithArgs(args []string) BinaryOpts {
 	}
 }
 
+// WithProtocol selects the wire protocol the shim serves its control
+// socket over: "ttrpc" (the default, used if this option is never passed)
+// or "grpc". Shims that want to reuse the gRPC ecosystem (auth,
+// reflection, health) instead of maintaining a parallel ttrpc stack opt in
+// with WithProtocol("grpc").
+func WithProtocol(protocol string) BinaryOpts {
+	return func(config *Config) {
+		config.Protocol = protocol
+	}
+}
+
 func WithStdio(stdin io.ReadCloser, stdout io.WriteCloser) BinaryOpts {
 	return func(config *Config) {
 		config.Stdin = stdin
@@ -144,10 +199,13 @@ var (
 	versionFlag          bool
 	infoFlag             bool
 	id                   string
+	sandboxIDFlag        string
 	namespaceFlag        string
 	socketFlag           string
 	debugSocketFlag      string
 	bundlePath           string
+	configFlag           string
+	logSinkFlag          string
 	addressFlag          string
 	containerdBinaryFlag string
 	action               string
@@ -168,9 +226,12 @@ func parseFlags(args []string) {
 	flag.BoolVar(&infoFlag, "info", false, "get the option protobuf from stdin, print the shim info protobuf to stdout, and exit")
 	flag.StringVar(&namespaceFlag, "namespace", "", "namespace that owns the shim")
 	flag.StringVar(&id, "id", "", "id of the task")
+	flag.StringVar(&sandboxIDFlag, "sandbox-id", "", "id of the sandbox this shim belongs to, for runtimes that host every task in a pod under one shim process")
 	flag.StringVar(&socketFlag, "socket", "", "socket path to serve")
 	flag.StringVar(&debugSocketFlag, "debug-socket", "", "debug socket path to serve")
 	flag.StringVar(&bundlePath, "bundle", "", "path to the bundle if not workdir")
+	flag.StringVar(&configFlag, "config", "", fmt.Sprintf("path to shim plugin configuration, defaults to %s in the bundle", defaultConfigFileName))
+	flag.StringVar(&logSinkFlag, "log-sink", "", "id of a registered LogSink plugin to send structured logs to, instead of the default FIFO")
 
 	flag.StringVar(&addressFlag, "address", "", "grpc address back to main containerd")
 	flag.StringVar(&containerdBinaryFlag, "publish-binary", "",
@@ -196,6 +257,36 @@ func setRuntime() {
 	}
 }
 
+// socketID returns the key used to address this shim's control socket: the
+// sandbox ID when the shim is sandbox-scoped (started via StartSandbox and
+// reached by sibling containers through Attach), or the task ID otherwise.
+func socketID() string {
+	if sandboxIDFlag != "" {
+		return sandboxIDFlag
+	}
+	return id
+}
+
+// shimConfigPath returns the file plugin configuration is read from: the
+// -config flag if given, otherwise defaultConfigFileName in the bundle.
+func shimConfigPath() string {
+	if configFlag != "" {
+		return configFlag
+	}
+	return filepath.Join(bundlePath, defaultConfigFileName)
+}
+
+// sandboxPluginStateSubdir returns the path segment, relative to the bundle,
+// under which this process's plugin state is rooted. For a sandbox-scoped
+// shim it is the starting container's id, keeping a later sibling's state
+// (once per-task plugin init exists) from landing in the same directory.
+func sandboxPluginStateSubdir() string {
+	if sandboxIDFlag != "" {
+		return id
+	}
+	return ""
+}
+
 func setLogger(ctx context.Context, id string) (context.Context, error) {
 	l := log.G(ctx)
 	_ = log.SetFormat(log.TextFormat)
@@ -248,6 +339,16 @@ func runInfo(ctx context.Context, manager Manager, config Config) error {
 	if err != nil {
 		return err
 	}
+
+	if shimCfg, err := loadShimConfig(shimConfigPath()); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to load shim config for info dump")
+	} else if b, err := toml.Marshal(shimCfg); err == nil {
+		if info.Annotations == nil {
+			info.Annotations = map[string]string{}
+		}
+		info.Annotations[shimConfigAnnotation] = string(b)
+	}
+
 	infoB, err := proto.Marshal(info)
 	if err != nil {
 		return err
@@ -307,7 +408,21 @@ func run(ctx context.Context, manager Manager, config Config) error {
 			logger.Logger.SetLevel(log.DebugLevel)
 		}
 		go reap(ctx, logger, signals)
-		ss, err := manager.Stop(ctx, id)
+
+		// Deleting the task whose id is the sandbox id itself is the
+		// signal that the sandbox, not just one sibling container in it,
+		// is going away: dispatch to StopSandbox instead of Stop so a
+		// sandbox-scoped shim's process lifecycle actually gets torn down.
+		var ss StopStatus
+		if sandboxIDFlag != "" && id == sandboxIDFlag {
+			sm, ok := manager.(SandboxManager)
+			if !ok {
+				return fmt.Errorf("%s does not support sandbox-scoped shims", manager.Name())
+			}
+			ss, err = sm.StopSandbox(ctx, sandboxIDFlag)
+		} else {
+			ss, err = manager.Stop(ctx, id)
+		}
 		if err != nil {
 			return err
 		}
@@ -324,13 +439,27 @@ func run(ctx context.Context, manager Manager, config Config) error {
 		}
 		return nil
 	case "start":
+		protocol := config.Protocol
+		if protocol == "" {
+			protocol = "ttrpc"
+		}
 		opts := StartOpts{
 			Address:      addressFlag,
 			TTRPCAddress: ttrpcAddress,
 			Debug:        debugFlag,
+			Protocol:     protocol,
 		}
 
-		params, err := manager.Start(ctx, id, opts)
+		var params BootstrapParams
+		if sandboxIDFlag != "" {
+			sm, ok := manager.(SandboxManager)
+			if !ok {
+				return fmt.Errorf("%s does not support sandbox-scoped shims", manager.Name())
+			}
+			params, err = sm.StartSandbox(ctx, sandboxIDFlag, opts)
+		} else {
+			params, err = manager.Start(ctx, id, opts)
+		}
 		if err != nil {
 			return fmt.Errorf("starting manager: %w", err)
 		}
@@ -344,6 +473,30 @@ func run(ctx context.Context, manager Manager, config Config) error {
 			return fmt.Errorf("writing bootstrap params: %w", err)
 		}
 
+		return nil
+	case "attach":
+		sm, ok := manager.(SandboxManager)
+		if !ok {
+			return fmt.Errorf("%s does not support attaching to a running sandbox shim", manager.Name())
+		}
+		if sandboxIDFlag == "" {
+			return fmt.Errorf("attach requires -sandbox-id")
+		}
+
+		params, err := sm.Attach(ctx, sandboxIDFlag)
+		if err != nil {
+			return fmt.Errorf("attaching to sandbox shim: %w", err)
+		}
+
+		data, err := json.Marshal(&params)
+		if err != nil {
+			return fmt.Errorf("failed to marshal bootstrap params to json: %w", err)
+		}
+
+		if _, err := config.Stdout.Write(data); err != nil {
+			return fmt.Errorf("writing bootstrap params: %w", err)
+		}
+
 		return nil
 	}
 
@@ -379,13 +532,21 @@ func run(ctx context.Context, manager Manager, config Config) error {
 		},
 	})
 
+	shimConfig, err := loadShimConfig(shimConfigPath())
+	if err != nil {
+		return fmt.Errorf("loading shim config: %w", err)
+	}
+
 	var (
 		initialized   = plugin.NewPluginSet()
 		ttrpcServices = []TTRPCService{}
+		grpcServices  = []GRPCService{}
 
 		ttrpcUnaryInterceptors = []ttrpc.UnaryServerInterceptor{}
+		grpcUnaryInterceptors  = []grpc.UnaryServerInterceptor{}
 
 		pprofHandler server
+		logSinks     = map[string]LogSink{}
 	)
 
 	// log.G(ctx).WithFields(log.Fields{
@@ -410,25 +571,30 @@ func run(ctx context.Context, manager Manager, config Config) error {
 				// shim plugins should make use state directory for writing files to disk.
 				// The state directory will be destroyed when the shim if cleaned up or
 				// on reboot
-				plugins.PropertyStateDir:     filepath.Join(bundlePath, p.URI()),
+				//
+				// For a sandbox-scoped shim, bundlePath is the sandbox's bundle, and
+				// this loop only runs once for the process that hosts every container
+				// in the sandbox; nest the state dir under the starting container's id
+				// so its plugin state doesn't collide with a sibling container's if one
+				// attaches later.
+				plugins.PropertyStateDir:     filepath.Join(bundlePath, sandboxPluginStateSubdir(), p.URI()),
 				plugins.PropertyGRPCAddress:  addressFlag,
 				plugins.PropertyTTRPCAddress: ttrpcAddress,
 			},
 		)
 
-		// load the plugin specific configuration if it is provided
-		// TODO: Read configuration passed into shim, or from state directory?
-		// if p.Config != nil && initContext.Config == nil {
-		// pc, err := config.Decode(p)
-		// 	// if err != nil {
-		// 	// 	return nil, err
-		// 	// }
-		// 	initContext.Config = p.Config
-		// }
-
-		// if initContext.Config == nil {
-		// 	log.G(ctx).Warnf("plugin %s: config is nil", pID)
-		// }
+		// load the plugin specific configuration, if the shim config carries
+		// a section for it; a plugin whose section fails to decode is
+		// skipped rather than started unconfigured, since the two rarely
+		// behave the same way.
+		if p.Config != nil {
+			pc, err := shimConfig.Decode(pID, p.Config)
+			if err != nil {
+				log.G(ctx).WithFields(log.Fields{"id": pID, "type": p.Type, "error": err}).Error("skip loading plugin: invalid configuration")
+				continue
+			}
+			initContext.Config = pc
+		}
 
 		result := p.Init(initContext)
 		if err := initialized.Add(result); err != nil {
@@ -453,6 +619,19 @@ func run(ctx context.Context, manager Manager, config Config) error {
 			ttrpcUnaryInterceptors = append(ttrpcUnaryInterceptors, src.UnaryServerInterceptor())
 		}
 
+		if src, ok := instance.(GRPCService); ok {
+			log.G(ctx).WithField("id", pID).Debug("registering grpc service")
+			grpcServices = append(grpcServices, src)
+		}
+
+		if src, ok := instance.(GRPCServerUnaryOptioner); ok {
+			grpcUnaryInterceptors = append(grpcUnaryInterceptors, src.UnaryServerInterceptor())
+		}
+
+		if src, ok := instance.(LogSink); ok {
+			logSinks[result.Registration.ID] = src
+		}
+
 		if result.Registration.ID == "pprof" {
 			if src, ok := instance.(server); ok {
 				pprofHandler = src
@@ -460,27 +639,70 @@ func run(ctx context.Context, manager Manager, config Config) error {
 		}
 	}
 
-	if len(ttrpcServices) == 0 {
-		return fmt.Errorf("required that ttrpc service")
+	if sinkID := effectiveLogSink(shimConfig); sinkID != "" {
+		sink, ok := logSinks[sinkID]
+		if !ok {
+			return fmt.Errorf("log sink %q not found among loaded plugins", sinkID)
+		}
+		hook, err := sink.LogHook()
+		if err != nil {
+			return fmt.Errorf("initializing log sink %q: %w", sinkID, err)
+		}
+		// Logs emitted before this point (plugin loading itself) already
+		// went to the FIFO opened by setLogger; from here on structured
+		// output goes to the sink instead and the FIFO falls quiet.
+		logger := log.G(ctx)
+		logger.Logger.AddHook(hook)
+		logger.Logger.SetOutput(io.Discard)
 	}
 
-	unaryInterceptor := chainUnaryServerInterceptors(ttrpcUnaryInterceptors...)
-	server, err := newServer(ttrpc.WithUnaryServerInterceptor(unaryInterceptor))
-	if err != nil {
-		return fmt.Errorf("failed creating server: %w", err)
+	protocol := config.Protocol
+	if protocol == "" {
+		protocol = "ttrpc"
 	}
 
-	for _, srv := range ttrpcServices {
-		if err := srv.RegisterTTRPC(server); err != nil {
-			return fmt.Errorf("failed to register service: %w", err)
+	var serveErr error
+	switch protocol {
+	case "grpc":
+		if len(grpcServices) == 0 {
+			return fmt.Errorf("required that grpc service")
+		}
+
+		grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(grpcUnaryInterceptors...))
+		for _, srv := range grpcServices {
+			if err := srv.RegisterGRPC(grpcServer); err != nil {
+				return fmt.Errorf("failed to register service: %w", err)
+			}
+		}
+
+		serveErr = serveGRPC(ctx, grpcServer, signals, sd.Shutdown, pprofHandler)
+	case "ttrpc":
+		if len(ttrpcServices) == 0 {
+			return fmt.Errorf("required that ttrpc service")
+		}
+
+		unaryInterceptor := chainUnaryServerInterceptors(ttrpcUnaryInterceptors...)
+		server, err := newServer(ttrpc.WithUnaryServerInterceptor(unaryInterceptor))
+		if err != nil {
+			return fmt.Errorf("failed creating server: %w", err)
+		}
+
+		for _, srv := range ttrpcServices {
+			if err := srv.RegisterTTRPC(server); err != nil {
+				return fmt.Errorf("failed to register service: %w", err)
+			}
 		}
+
+		serveErr = serve(ctx, server, signals, sd.Shutdown, pprofHandler)
+	default:
+		return fmt.Errorf("unsupported shim protocol %q", protocol)
 	}
 
-	if err := serve(ctx, server, signals, sd.Shutdown, pprofHandler); err != nil {
+	if serveErr != nil {
 		fmt.Fprintf(os.Stderr, "SHIM_EXIT: pid=%d code=1\n", os.Getpid())
-		if !errors.Is(err, shutdown.ErrShutdown) {
+		if !errors.Is(serveErr, shutdown.ErrShutdown) {
 			cleanupSockets(ctx)
-			return fmt.Errorf("serving: %w", err)
+			return fmt.Errorf("serving: %w", serveErr)
 		}
 	}
 
@@ -499,6 +721,25 @@ func run(ctx context.Context, manager Manager, config Config) error {
 // serve serves the ttrpc API over a unix socket in the current working directory
 // and blocks until the context is canceled
 func serve(ctx context.Context, server *ttrpc.Server, signals chan os.Signal, shutdown func(), pprof server) error {
+	return serveRPC(ctx, signals, shutdown, pprof, func(l net.Listener) error {
+		return server.Serve(ctx, l)
+	})
+}
+
+// serveGRPC serves the gRPC API over a unix socket in the current working
+// directory and blocks until the context is canceled, mirroring serve.
+func serveGRPC(ctx context.Context, server *grpc.Server, signals chan os.Signal, shutdown func(), pprof server) error {
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	return serveRPC(ctx, signals, shutdown, pprof, server.Serve)
+}
+
+// serveRPC hosts the plumbing common to both protocols: opening the control
+// socket, wiring up pprof, stack dumps and exit-signal handling, and
+// reaping children until run returns.
+func serveRPC(ctx context.Context, signals chan os.Signal, shutdown func(), pprof server, run func(net.Listener) error) error {
 	dump := make(chan os.Signal, 32)
 	setupDumpStacks(dump)
 
@@ -513,8 +754,8 @@ func serve(ctx context.Context, server *ttrpc.Server, signals chan os.Signal, sh
 	}
 	go func() {
 		defer l.Close()
-		if err := server.Serve(ctx, l); err != nil && !errors.Is(err, net.ErrClosed) {
-			log.G(ctx).WithError(err).Fatal("containerd-shim: ttrpc server failure")
+		if err := run(l); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.G(ctx).WithError(err).Fatal("containerd-shim: rpc server failure")
 		}
 	}()
 
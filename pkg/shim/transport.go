@@ -0,0 +1,78 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport abstracts how a shim control address is dialed, listened on and
+// cleaned up. It is keyed by URI scheme (the part before "://") in the
+// TransportRegistry, so third parties can plug in new shim addressing
+// schemes (e.g. QUIC, gRPC-over-mTLS) without patching containerd.
+//
+// addr is always the address with its scheme prefix already stripped.
+type Transport interface {
+	// Dial connects to addr within timeout.
+	Dial(addr string, timeout time.Duration) (net.Conn, error)
+	// Listen creates a listener bound to addr.
+	Listen(addr string) (net.Listener, error)
+	// Cleanup removes any persistent state (e.g. a socket file) left
+	// behind for addr. It must be safe to call on an address that was
+	// never listened on.
+	Cleanup(addr string) error
+	// CanConnect reports whether addr is currently accepting connections.
+	CanConnect(addr string) bool
+}
+
+// TransportRegistry dispatches shim addresses to a Transport by URI scheme.
+type TransportRegistry struct {
+	mu         sync.RWMutex
+	transports map[string]Transport
+}
+
+func newTransportRegistry() *TransportRegistry {
+	return &TransportRegistry{transports: make(map[string]Transport)}
+}
+
+// defaultTransports holds the process-wide set of registered transports,
+// analogous to the plugin registry used elsewhere in containerd.
+var defaultTransports = newTransportRegistry()
+
+// RegisterTransport registers t as the handler for addresses of the form
+// "<scheme>://...". It panics if scheme is already registered.
+func RegisterTransport(scheme string, t Transport) {
+	defaultTransports.mu.Lock()
+	defer defaultTransports.mu.Unlock()
+	if _, ok := defaultTransports.transports[scheme]; ok {
+		panic(fmt.Errorf("shim: transport %q already registered", scheme))
+	}
+	defaultTransports.transports[scheme] = t
+}
+
+func (r *TransportRegistry) get(scheme string) (Transport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.transports[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol: %s", scheme)
+	}
+	return t, nil
+}
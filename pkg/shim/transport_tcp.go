@@ -0,0 +1,144 @@
+//go:build !windows
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// protoTCP and protoTLS let out-of-node shims (e.g. a shim living in a
+// remote hypervisor host or a confidential-VM control plane) be addressed
+// uniformly alongside the local-machine unix/vsock/hvsock transports.
+const (
+	protoTCP = "tcp"
+	protoTLS = "tls"
+)
+
+func init() {
+	RegisterTransport(protoTCP, tcpTransport{})
+	RegisterTransport(protoTLS, tlsTransport{})
+}
+
+// tcpTransport is the built-in Transport for "tcp://host:port" addresses.
+type tcpTransport struct{}
+
+func (tcpTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
+func (tcpTransport) Listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func (tcpTransport) Cleanup(string) error {
+	// TCP sockets have no on-disk state to remove.
+	return nil
+}
+
+func (t tcpTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// tlsCredDirEnv overrides the directory tlsTransport reads cert.pem,
+// key.pem and ca.pem from. When unset, it defaults to the directory
+// containing the shim's -address flag value.
+const tlsCredDirEnv = "SHIM_TLS_CREDENTIALS_DIR"
+
+// tlsTransport is the built-in Transport for "tls://host:port" addresses.
+// It layers mutual TLS over a plain TCP connection, reading the cert/key
+// pair and CA bundle from a directory next to addressFlag, or from
+// tlsCredDirEnv.
+type tlsTransport struct{}
+
+func tlsCredDir() string {
+	if dir := os.Getenv(tlsCredDirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Dir(addressFlag)
+}
+
+func (tlsTransport) config() (*tls.Config, error) {
+	dir := tlsCredDir()
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("tls: loading shim certificate from %s: %w", dir, err)
+	}
+
+	caBytes, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("tls: loading CA bundle from %s: %w", dir, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", filepath.Join(dir, "ca.pem"))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func (t tlsTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	cfg, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: timeout}
+	return tls.DialWithDialer(d, "tcp", addr, cfg)
+}
+
+func (t tlsTransport) Listen(addr string) (net.Listener, error) {
+	cfg, err := t.config()
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l, cfg), nil
+}
+
+func (tlsTransport) Cleanup(string) error {
+	return nil
+}
+
+func (t tlsTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,105 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// recordingTransport is a fake Transport that records which method was
+// called with which (scheme-stripped) address, so tests can assert that
+// AnonDialer/NewSocket/RemoveSocket actually reach it instead of
+// silently falling through to some other code path.
+type recordingTransport struct {
+	dialed    string
+	listened  string
+	cleaned   string
+	listenErr error
+}
+
+func (t *recordingTransport) Dial(addr string, _ time.Duration) (net.Conn, error) {
+	t.dialed = addr
+	return nil, errors.New("recordingTransport: no real connection")
+}
+
+func (t *recordingTransport) Listen(addr string) (net.Listener, error) {
+	t.listened = addr
+	if t.listenErr != nil {
+		return nil, t.listenErr
+	}
+	return &net.TCPListener{}, nil
+}
+
+func (t *recordingTransport) Cleanup(addr string) error {
+	t.cleaned = addr
+	return nil
+}
+
+func (t *recordingTransport) CanConnect(string) bool {
+	return true
+}
+
+const testTransportScheme = "shimtest"
+
+func TestNewSocketDispatchesThroughRegisteredTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	RegisterTransport(testTransportScheme, rt)
+
+	if _, err := NewSocket(testTransportScheme + "://some-addr"); err != nil {
+		t.Fatalf("NewSocket returned error: %v", err)
+	}
+	if rt.listened != "some-addr" {
+		t.Fatalf("expected registered Transport.Listen to be called with %q, got %q", "some-addr", rt.listened)
+	}
+}
+
+func TestNewSocketUnknownScheme(t *testing.T) {
+	if _, err := NewSocket("does-not-exist://addr"); err == nil {
+		t.Fatal("expected an error for an unregistered scheme, got nil")
+	}
+}
+
+func TestAnonDialerDispatchesThroughRegisteredTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	RegisterTransport(testTransportScheme+"-dial", rt)
+
+	_, _ = AnonDialer(testTransportScheme+"-dial://some-addr", time.Second)
+	if rt.dialed != "some-addr" {
+		t.Fatalf("expected registered Transport.Dial to be called with %q, got %q", "some-addr", rt.dialed)
+	}
+}
+
+func TestRemoveSocketDispatchesThroughRegisteredTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	RegisterTransport(testTransportScheme+"-cleanup", rt)
+
+	if err := RemoveSocket(testTransportScheme + "-cleanup://some-addr"); err != nil {
+		t.Fatalf("RemoveSocket returned error: %v", err)
+	}
+	if rt.cleaned != "some-addr" {
+		t.Fatalf("expected registered Transport.Cleanup to be called with %q, got %q", "some-addr", rt.cleaned)
+	}
+}
+
+func TestTransportRegistryGetUnknownScheme(t *testing.T) {
+	if _, err := defaultTransports.get("no-such-scheme-registered"); err == nil {
+		t.Fatal("expected an error looking up an unregistered scheme, got nil")
+	}
+}
@@ -0,0 +1,36 @@
+//go:build solaris || illumos
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+// protoDoor identifies a Solaris/illumos shim control address. Native door
+// RPCs (door_create/door_call) have no cgo-free binding available in this
+// tree, so doorTransport proxies over the same AF_UNIX socket machinery as
+// protoUnix today by embedding it; the scheme exists so a future
+// door_create/door_call binding can be dropped in here without touching
+// callers that already address shims as "door://...".
+const protoDoor = "door"
+
+func init() {
+	RegisterTransport(protoDoor, doorTransport{})
+}
+
+// doorTransport is the built-in Transport for "door://" addresses.
+type doorTransport struct {
+	unixTransport
+}
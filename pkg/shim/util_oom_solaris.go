@@ -0,0 +1,27 @@
+//go:build solaris || illumos
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+// AdjustOOMScore is a no-op on Solaris/illumos. There is no
+// /proc/<pid>/oom_score_adj equivalent; the closest analogues are resource
+// controls (rctl/projects), which are a host-configured policy rather than
+// something a shim can usefully twiddle per-process at startup.
+func AdjustOOMScore(pid int) error {
+	return nil
+}
@@ -1,5 +1,14 @@
 //go:build !windows
 
+// This file holds the logic shared by every non-Windows platform: unix
+// sockets, the hybrid-vsock handshake (a plain AF_UNIX proxy, not native
+// AF_VSOCK) and the per-scheme choice of DialPolicy (defined in policy.go).
+// Platform-specific pieces that don't build or
+// behave the same everywhere live in their own build-tagged files:
+// AdjustOOMScore (util_oom_unix.go, util_oom_solaris.go) and native AF_VSOCK
+// dialing (util_vsock_linux.go), which depends on a Linux-only kernel
+// feature.
+
 /*
    Copyright The containerd Authors.
 
@@ -24,7 +33,6 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
-	"io"
 	"math"
 	"net"
 	"os"
@@ -35,20 +43,17 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/containerd/log"
-	"github.com/mdlayher/vsock"
-
 	"github.com/containerd/containerd/v2/defaults"
 	"github.com/containerd/containerd/v2/pkg/namespaces"
-	"github.com/containerd/containerd/v2/pkg/sys"
 )
 
 const (
-	shimBinaryFormat = "containerd-shim-%s-%s"
-	socketPathLimit  = 106
-	protoVsock       = "vsock"
-	protoHybridVsock = "hvsock"
-	protoUnix        = "unix"
+	shimBinaryFormat  = "containerd-shim-%s-%s"
+	socketPathLimit   = 106
+	protoVsock        = "vsock"
+	protoHybridVsock  = "hvsock"
+	protoUnix         = "unix"
+	protoUnixAbstract = "unix-abstract"
 )
 
 func getSysProcAttr() *syscall.SysProcAttr {
@@ -57,26 +62,17 @@ func getSysProcAttr() *syscall.SysProcAttr {
 	}
 }
 
-// AdjustOOMScore sets the OOM score for the process to the parents OOM score +1
-// to ensure that they parent has a lower* score than the shim
-// if not already at the maximum OOM Score
-func AdjustOOMScore(pid int) error {
-	parent := os.Getppid()
-	score, err := sys.GetOOMScoreAdj(parent)
-	if err != nil {
-		return fmt.Errorf("get parent OOM score: %w", err)
-	}
-	shimScore := score + 1
-	if err := sys.AdjustOOMScore(pid, shimScore); err != nil {
-		return fmt.Errorf("set shim OOM score: %w", err)
-	}
-	return nil
-}
-
 const socketRoot = defaults.DefaultStateDir
 
 // SocketAddress returns a socket address
 func SocketAddress(ctx context.Context, socketPath, id string, debug bool) (string, error) {
+	return SocketAddressForScheme(ctx, protoUnix, socketPath, id, debug)
+}
+
+// SocketAddressForScheme returns a socket address using scheme in place of
+// the default "unix" prefix, so shims addressed over a non-filesystem
+// transport (tcp, tls, vsock, ...) can advertise it back to containerd.
+func SocketAddressForScheme(ctx context.Context, scheme, socketPath, id string, debug bool) (string, error) {
 	ns, err := namespaces.NamespaceRequired(ctx)
 	if err != nil {
 		return "", err
@@ -88,26 +84,27 @@ func SocketAddress(ctx context.Context, socketPath, id string, debug bool) (stri
 	d := sha256.Sum256([]byte(path))
 
 	sockroot := filepath.Dir(addressFlag)
-	return fmt.Sprintf("unix://%s/%x", filepath.Join(sockroot, "s"), d), nil
+	return fmt.Sprintf("%s://%s/%x", scheme, filepath.Join(sockroot, "s"), d), nil
 }
 
-// AnonDialer returns a dialer for a socket
+// AnonDialer returns a dialer for a socket, dispatching by URI scheme to
+// whatever Transport is registered for it. An address with no "scheme://"
+// prefix is always treated as an abstract-namespace unix socket.
+//
+// It retries using the historical policy for the address's scheme: hybrid
+// vsock dials make up to 10 attempts on a transient handshake, everything
+// else is a single attempt. Callers that want exponential backoff and
+// jitter, e.g. for a shim booting inside a VM, should use
+// AnonDialerWithPolicy instead.
 func AnonDialer(address string, timeout time.Duration) (net.Conn, error) {
-	proto, addr, ok := strings.Cut(address, "://")
-	if !ok {
-		return net.DialTimeout("unix", socket(address).path(), timeout)
-	}
-	switch proto {
-	case protoVsock:
-		// vsock dialer can not set timeout
-		return dialVsock(addr)
-	case protoHybridVsock:
-		return dialHybridVsock(addr, timeout)
-	case protoUnix:
-		return net.DialTimeout("unix", socket(address).path(), timeout)
-	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", proto)
+	scheme, _, ok := strings.Cut(address, "://")
+	policy := defaultDialPolicy
+	if ok && scheme == protoHybridVsock {
+		policy = defaultHybridVsockDialPolicy(timeout)
+	} else {
+		policy.PerAttemptDeadline = timeout
 	}
+	return AnonDialerWithPolicy(address, policy)
 }
 
 // AnonReconnectDialer returns a dialer for an existing socket on reconnection
@@ -115,35 +112,54 @@ func AnonReconnectDialer(address string, timeout time.Duration) (net.Conn, error
 	return AnonDialer(address, timeout)
 }
 
-// NewSocket returns a new socket
-func NewSocket(address string) (*net.UnixListener, error) {
-	var (
-		sock       = socket(address)
-		path       = sock.path()
-		isAbstract = sock.isAbstract()
-		perm       = os.FileMode(0600)
-	)
+// NewSocket returns a new listener for address, dispatching by URI scheme to
+// whatever Transport is registered for it — this is the Listen-side
+// counterpart to AnonDialer. An address with no "scheme://" prefix is
+// created as an abstract-namespace unix socket, matching historical
+// behavior.
+func NewSocket(address string) (net.Listener, error) {
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		return newUnixListener(address, true)
+	}
+	t, err := defaultTransports.get(scheme)
+	if err != nil {
+		return nil, err
+	}
+	return t.Listen(addr)
+}
+
+// newUnixListener creates the actual filesystem or abstract-namespace unix
+// socket. When abstract is true, path is treated as the raw abstract name
+// (the historical behavior for addresses given with no scheme), otherwise
+// path is a concrete filesystem path.
+func newUnixListener(path string, abstract bool) (*net.UnixListener, error) {
+	finalPath := path
+	if abstract {
+		finalPath = abstractSocketPrefix + path
+	}
 
 	// Darwin needs +x to access socket, otherwise it'll fail with "bind: permission denied" when running as non-root.
+	perm := os.FileMode(0600)
 	if runtime.GOOS == "darwin" {
 		perm = 0700
 	}
 
-	if !isAbstract {
-		if err := os.MkdirAll(filepath.Dir(path), perm); err != nil {
-			return nil, fmt.Errorf("mkdir failed for %s: %w", path, err)
+	if !abstract {
+		if err := os.MkdirAll(filepath.Dir(finalPath), perm); err != nil {
+			return nil, fmt.Errorf("mkdir failed for %s: %w", finalPath, err)
 		}
 	}
-	l, err := net.Listen("unix", path)
+	l, err := net.Listen("unix", finalPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if !isAbstract {
-		if err := os.Chmod(path, perm); err != nil {
-			os.Remove(sock.path())
+	if !abstract {
+		if err := os.Chmod(finalPath, perm); err != nil {
+			os.Remove(finalPath)
 			l.Close()
-			return nil, fmt.Errorf("chmod failed for %s: %w", path, err)
+			return nil, fmt.Errorf("chmod failed for %s: %w", finalPath, err)
 		}
 	}
 
@@ -167,14 +183,20 @@ func (s socket) path() string {
 	return path
 }
 
-// RemoveSocket removes the socket at the specified address if
-// it exists on the filesystem
+// RemoveSocket removes any persistent state left behind by the listener at
+// address, dispatching to the registered Transport's Cleanup for addresses
+// that carry a scheme. Abstract-namespace sockets have no filesystem entry
+// to remove.
 func RemoveSocket(address string) error {
-	sock := socket(address)
-	if !sock.isAbstract() {
-		return os.Remove(sock.path())
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		return nil
 	}
-	return nil
+	t, err := defaultTransports.get(scheme)
+	if err != nil {
+		return err
+	}
+	return t.Cleanup(addr)
 }
 
 // SocketEaddrinuse returns true if the provided error is caused by the
@@ -193,82 +215,64 @@ func SocketEaddrinuse(err error) bool {
 // CanConnect returns true if the socket provided at the address
 // is accepting new connections
 func CanConnect(address string) bool {
-	conn, err := AnonDialer(address, 100*time.Millisecond)
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		conn, err := net.DialTimeout("unix", socket(address).path(), 100*time.Millisecond)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	t, err := defaultTransports.get(scheme)
 	if err != nil {
 		return false
 	}
-	conn.Close()
-	return true
+	return t.CanConnect(addr)
 }
 
-func hybridVsockDialer(addr string, port uint64, timeout time.Duration) (net.Conn, error) {
-	timeoutCh := time.After(timeout)
-	// Do 10 retries before timeout
-	retryInterval := timeout / 10
-	for {
-		conn, err := net.DialTimeout("unix", addr, timeout)
-		if err != nil {
-			return nil, err
-		}
-		if _, err = fmt.Fprintln(conn, "CONNECT", port); err != nil {
-			conn.Close()
-			return nil, err
-		}
-		errChan := make(chan error, 1)
-		go func() {
-			reader := bufio.NewReader(conn)
-			response, err := reader.ReadString('\n')
-			if err != nil {
-				errChan <- err
-				return
-			}
-			if strings.Contains(response, "OK") {
-				errChan <- nil
-			} else {
-				errChan <- fmt.Errorf("hybrid vsock handshake response error: %s", response)
-			}
-		}()
-		select {
-		case err = <-errChan:
-			if err != nil {
-				conn.Close()
-				// When it is EOF, maybe the server side is not ready.
-				if err == io.EOF {
-					log.G(context.Background()).Warnf("Read hybrid vsock got EOF, server may not ready")
-					time.Sleep(retryInterval)
-					continue
-				}
-				return nil, err
-			}
-			return conn, nil
-		case <-timeoutCh:
-			conn.Close()
-			return nil, fmt.Errorf("timeout waiting for hybrid vsocket handshake of %s:%d", addr, port)
-		}
-	}
+// handshakeError represents a non-OK hybrid-vsock handshake response, e.g.
+// "BUSY" or "STARTING" while the guest agent is still coming up. It is
+// distinguished from a hard failure so a DialPolicy's Retryable classifier
+// can tell the two apart.
+type handshakeError struct {
+	response string
+}
 
+func (e *handshakeError) Error() string {
+	return fmt.Sprintf("hybrid vsock handshake response error: %s", e.response)
 }
 
-func dialVsock(address string) (net.Conn, error) {
-	contextIDString, portString, ok := strings.Cut(address, ":")
-	if !ok {
-		return nil, fmt.Errorf("invalid vsock address %s", address)
-	}
-	contextID, err := strconv.ParseUint(contextIDString, 10, 0)
+// hybridVsockDialer performs a single CONNECT handshake attempt over a unix
+// socket proxy. Retrying a not-yet-ready peer is the caller's
+// responsibility via AnonDialerWithPolicy, rather than being hard-coded
+// here.
+func hybridVsockDialer(addr string, port uint64, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", addr, timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse vsock context id %s, %v", contextIDString, err)
+		return nil, err
 	}
-	if contextID > math.MaxUint32 {
-		return nil, fmt.Errorf("vsock context id %d is invalid", contextID)
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
 	}
-	port, err := strconv.ParseUint(portString, 10, 0)
+	if _, err = fmt.Fprintln(conn, "CONNECT", port); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := reader.ReadString('\n')
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse vsock port %s, %v", portString, err)
+		conn.Close()
+		return nil, err
 	}
-	if port > math.MaxUint32 {
-		return nil, fmt.Errorf("vsock port %d is invalid", port)
+	if !strings.Contains(response, "OK") {
+		conn.Close()
+		return nil, &handshakeError{response: strings.TrimSpace(response)}
 	}
-	return vsock.Dial(uint32(contextID), uint32(port), &vsock.Config{})
+
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
 }
 
 func dialHybridVsock(address string, timeout time.Duration) (net.Conn, error) {
@@ -286,18 +290,122 @@ func dialHybridVsock(address string, timeout time.Duration) (net.Conn, error) {
 	return hybridVsockDialer(addr, port, timeout)
 }
 
+func init() {
+	RegisterTransport(protoUnix, unixTransport{})
+	RegisterTransport(protoUnixAbstract, abstractUnixTransport{})
+	RegisterTransport(protoHybridVsock, hybridVsockTransport{})
+}
+
+// unixTransport is the built-in Transport for "unix://" addresses, backed
+// by a real filesystem socket (never abstract-namespace; that case is
+// handled directly by AnonDialer/NewSocket for addresses with no scheme).
+type unixTransport struct{}
+
+func (unixTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", addr, timeout)
+}
+
+func (unixTransport) Listen(addr string) (net.Listener, error) {
+	return newUnixListener(addr, false)
+}
+
+func (unixTransport) Cleanup(addr string) error {
+	return os.Remove(addr)
+}
+
+func (t unixTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// abstractUnixTransport is the built-in Transport for "unix-abstract://"
+// addresses: an abstract-namespace unix socket, which has no filesystem
+// entry and so sidesteps the 104-byte path limit for deeply nested bundle
+// paths. Cleanup is a no-op since there's no filesystem entry to remove.
+type abstractUnixTransport struct{}
+
+func (abstractUnixTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("unix", abstractSocketPrefix+addr, timeout)
+}
+
+func (abstractUnixTransport) Listen(addr string) (net.Listener, error) {
+	return newUnixListener(addr, true)
+}
+
+func (abstractUnixTransport) Cleanup(string) error {
+	return nil
+}
+
+func (t abstractUnixTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// hybridVsockTransport is the built-in Transport for "hvsock://" addresses,
+// a vsock-like proxy socket exposed through the host's filesystem (used by
+// some hypervisors in place of native AF_VSOCK).
+type hybridVsockTransport struct{}
+
+func (hybridVsockTransport) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	return dialHybridVsock(addr, timeout)
+}
+
+func (hybridVsockTransport) Listen(string) (net.Listener, error) {
+	return nil, fmt.Errorf("hvsock: listen not supported, the shim only dials out")
+}
+
+func (hybridVsockTransport) Cleanup(string) error {
+	return nil
+}
+
+func (t hybridVsockTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// schemedAddress returns flagValue as-is if it already carries a
+// "scheme://" prefix (e.g. a -socket of "vsock://3:1234" or
+// "unix-abstract://..."), otherwise it is a legacy plain filesystem path
+// and is given the default "unix://" prefix.
+func schemedAddress(flagValue string) string {
+	if strings.Contains(flagValue, "://") {
+		return flagValue
+	}
+	return "unix://" + flagValue
+}
+
+// cleanupSockets removes the control and debug sockets for this process.
+// It keys on socketID rather than the task id directly, since a
+// sandbox-scoped shim's sockets are addressed by its sandbox id so that
+// sibling containers attaching to it resolve to the same address.
+//
+// Cleanup itself is per-scheme, dispatched through the Transport registry:
+// a real unix socket is unlinked, an abstract-namespace or vsock socket has
+// no filesystem entry and is left alone.
 func cleanupSockets(ctx context.Context) {
 	if address, err := ReadAddress("address"); err == nil {
 		_ = RemoveSocket(address)
 	}
 	if len(socketFlag) > 0 {
-		_ = RemoveSocket("unix://" + socketFlag)
-	} else if address, err := SocketAddress(ctx, addressFlag, id, false); err == nil {
+		_ = RemoveSocket(schemedAddress(socketFlag))
+	} else if address, err := SocketAddress(ctx, addressFlag, socketID(), false); err == nil {
 		_ = RemoveSocket(address)
 	}
 	if len(debugSocketFlag) > 0 {
-		_ = RemoveSocket("unix://" + debugSocketFlag)
-	} else if address, err := SocketAddress(ctx, addressFlag, id, true); err == nil {
+		_ = RemoveSocket(schemedAddress(debugSocketFlag))
+	} else if address, err := SocketAddress(ctx, addressFlag, socketID(), true); err == nil {
 		_ = RemoveSocket(address)
 	}
 }
@@ -0,0 +1,102 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/vsock"
+)
+
+// AF_VSOCK is a Linux kernel feature, so native vsock dialing only builds
+// and registers here; other unix-like platforms fall back to whatever
+// transports they register (e.g. hvsock, or door on Solaris/illumos).
+func init() {
+	RegisterTransport(protoVsock, vsockTransport{})
+}
+
+func dialVsock(address string) (net.Conn, error) {
+	contextIDString, portString, ok := strings.Cut(address, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid vsock address %s", address)
+	}
+	contextID, err := strconv.ParseUint(contextIDString, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vsock context id %s, %v", contextIDString, err)
+	}
+	if contextID > math.MaxUint32 {
+		return nil, fmt.Errorf("vsock context id %d is invalid", contextID)
+	}
+	port, err := strconv.ParseUint(portString, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vsock port %s, %v", portString, err)
+	}
+	if port > math.MaxUint32 {
+		return nil, fmt.Errorf("vsock port %d is invalid", port)
+	}
+	return vsock.Dial(uint32(contextID), uint32(port), &vsock.Config{})
+}
+
+// listenVsock parses a "contextID:port" address and listens on AF_VSOCK, so
+// a shim started with -sandbox-id and a vsock:// address is reachable from
+// the host side of a microVM without a shared filesystem.
+func listenVsock(address string) (net.Listener, error) {
+	contextIDString, portString, ok := strings.Cut(address, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid vsock address %s", address)
+	}
+	contextID, err := strconv.ParseUint(contextIDString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vsock context id %s, %v", contextIDString, err)
+	}
+	port, err := strconv.ParseUint(portString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vsock port %s, %v", portString, err)
+	}
+	return vsock.ListenContextID(uint32(contextID), uint32(port), nil)
+}
+
+// vsockTransport is the built-in Transport for "vsock://" addresses, used to
+// reach a shim's control RPCs running inside a guest VM over AF_VSOCK.
+type vsockTransport struct{}
+
+func (vsockTransport) Dial(addr string, _ time.Duration) (net.Conn, error) {
+	// vsock dialer can not set timeout
+	return dialVsock(addr)
+}
+
+func (vsockTransport) Listen(addr string) (net.Listener, error) {
+	return listenVsock(addr)
+}
+
+func (vsockTransport) Cleanup(string) error {
+	return nil
+}
+
+func (t vsockTransport) CanConnect(addr string) bool {
+	conn, err := t.Dial(addr, 0)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -26,17 +26,45 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 
 	"github.com/containerd/log"
 	"golang.org/x/sys/unix"
 )
 
-// CreateUnixSocket creates a unix socket and returns the listener
+// CreateUnixSocket creates a listener and returns it. path may be a plain
+// filesystem path (legacy behavior, equivalent to "unix://path"), or a
+// URL-style address selecting the listener kind explicitly:
+//
+//   - "unix://<path>": a real filesystem unix socket.
+//   - "unix-abstract://<name>": an abstract-namespace unix socket, which
+//     has no filesystem entry and so sidesteps the 104-byte path limit for
+//     deeply nested bundle paths.
+//   - "vsock://<cid>:<port>": an AF_VSOCK listener, reachable from the host
+//     without a shared filesystem — the shape microVM-hosted shims like
+//     kata's need.
 func CreateUnixSocket(path string) (net.Listener, error) {
+	scheme, addr, ok := strings.Cut(path, "://")
+	if !ok {
+		return createUnixFileSocket(path)
+	}
+	switch scheme {
+	case "unix":
+		return createUnixFileSocket(addr)
+	case "unix-abstract":
+		return net.Listen("unix", "\x00"+addr)
+	case "vsock":
+		return createVsockListener(addr)
+	default:
+		return nil, fmt.Errorf("unsupported socket scheme: %s", scheme)
+	}
+}
+
+func createUnixFileSocket(path string) (net.Listener, error) {
 	// BSDs have a 104 limit
 	if len(path) > 104 {
-		return nil, fmt.Errorf("%q: unix socket path too long (> 104)", path)
+		return nil, fmt.Errorf("%q: unix socket path too long (> 104), use unix-abstract:// or vsock:// instead", path)
 	}
 	if err := os.MkdirAll(filepath.Dir(path), 0660); err != nil {
 		return nil, err
@@ -0,0 +1,44 @@
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sys
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mdlayher/vsock"
+)
+
+// createVsockListener listens on AF_VSOCK, a Linux kernel feature; other
+// platforms get the stub in socket_vsock_other.go.
+func createVsockListener(addr string) (net.Listener, error) {
+	cidString, portString, ok := strings.Cut(addr, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid vsock address %s, want CID:port", addr)
+	}
+	cid, err := strconv.ParseUint(cidString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsock context id %s: %w", cidString, err)
+	}
+	port, err := strconv.ParseUint(portString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsock port %s: %w", portString, err)
+	}
+	return vsock.ListenContextID(uint32(cid), uint32(port), nil)
+}
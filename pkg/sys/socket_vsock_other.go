@@ -0,0 +1,32 @@
+//go:build !windows && !linux
+
+/*
+   Copyright The containerd Authors.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sys
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// createVsockListener is unavailable here: AF_VSOCK is a Linux kernel
+// feature, and this package's Windows counterpart (not in this tree) would
+// expose hvsock instead.
+func createVsockListener(string) (net.Listener, error) {
+	return nil, fmt.Errorf("vsock listen not supported on %s", runtime.GOOS)
+}